@@ -0,0 +1,214 @@
+package logutil
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// OverflowPolicy determines what an AsyncStackdriverWriter does when its
+// queue is full.
+type OverflowPolicy int
+
+const (
+	// BlockOnFull blocks the caller until there's room in the queue.
+	BlockOnFull OverflowPolicy = iota
+	// DropOldest discards the oldest queued entry to make room for the new one.
+	DropOldest
+	// DropNewest discards the incoming entry instead of queuing it.
+	DropNewest
+)
+
+// asyncEntry is either a log entry (ack == nil) or a flush marker (ack !=
+// nil), which run closes once every entry queued ahead of it has been
+// written. Markers always take a blocking send, bypassing the overflow
+// policy, so Flush and Close can rely on the queue's FIFO order instead of
+// racing a separate counter.
+type asyncEntry struct {
+	level zerolog.Level
+	p     []byte
+	ack   chan struct{}
+}
+
+// AsyncStackdriverWriter wraps a StackdriverLoggingWriter so that Write and
+// WriteLevel hand entries to a background goroutine over a bounded channel,
+// instead of blocking the caller on Stackdriver's synchronous, gRPC-backed
+// Logger.Log. This keeps the hot log path of high-throughput services off
+// that shared mutex.
+//
+// When the queue fills, Policy decides whether callers block or entries get
+// dropped; drops are counted (see Dropped) and reported with a warn-level
+// log line.
+type AsyncStackdriverWriter struct {
+	w      *StackdriverLoggingWriter
+	policy OverflowPolicy
+
+	// mu guards closed against concurrent enqueues: Close takes the write
+	// lock to flip closed and close the queue, so no goroutine can be
+	// sending on (or about to send on) a closed channel.
+	mu     sync.RWMutex
+	closed bool
+
+	queue   chan asyncEntry
+	drain   sync.WaitGroup
+	dropped uint64
+}
+
+// NewAsyncStackdriverWriter starts a background goroutine that drains into
+// w, buffering up to bufferSize entries and applying policy once the buffer
+// fills.
+func NewAsyncStackdriverWriter(w *StackdriverLoggingWriter, bufferSize int, policy OverflowPolicy) *AsyncStackdriverWriter {
+	a := &AsyncStackdriverWriter{
+		w:      w,
+		policy: policy,
+		queue:  make(chan asyncEntry, bufferSize),
+	}
+
+	a.drain.Add(1)
+	go a.run()
+
+	return a
+}
+
+func (a *AsyncStackdriverWriter) run() {
+	defer a.drain.Done()
+	for e := range a.queue {
+		if e.ack != nil {
+			close(e.ack)
+			continue
+		}
+		a.w.WriteLevel(e.level, e.p)
+	}
+}
+
+// Write implements io.Writer.
+func (a *AsyncStackdriverWriter) Write(p []byte) (int, error) {
+	return a.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel implements zerolog.LevelWriter. It always returns len(p), nil;
+// entries lost to the overflow policy, or because the writer is closed, are
+// reflected in Dropped and a warn log, not in the return value.
+func (a *AsyncStackdriverWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	a.enqueue(asyncEntry{level: level, p: append([]byte(nil), p...)})
+	return len(p), nil
+}
+
+// enqueue applies the overflow policy (for log entries) or blocks (for
+// flush markers) to hand e to the background goroutine. It reports whether
+// e was queued; false means the writer is closed.
+func (a *AsyncStackdriverWriter) enqueue(e asyncEntry) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.closed {
+		if e.ack == nil {
+			a.drop()
+		}
+		return false
+	}
+
+	if e.ack != nil {
+		a.queue <- e
+		return true
+	}
+
+	switch a.policy {
+	case DropNewest:
+		select {
+		case a.queue <- e:
+			return true
+		default:
+			a.drop()
+			return false
+		}
+
+	case DropOldest:
+		select {
+		case a.queue <- e:
+			return true
+		default:
+		}
+		select {
+		case old := <-a.queue:
+			if old.ack != nil {
+				// Never evict a flush marker: put it back so its Flush
+				// still returns. It stays behind every entry queued
+				// before its Flush call.
+				a.queue <- old
+			} else {
+				a.drop()
+			}
+		default:
+		}
+		select {
+		case a.queue <- e:
+			return true
+		default:
+			a.drop()
+			return false
+		}
+
+	default: // BlockOnFull
+		a.queue <- e
+		return true
+	}
+}
+
+func (a *AsyncStackdriverWriter) drop() {
+	n := atomic.AddUint64(&a.dropped, 1)
+	log.Warn().Uint64("dropped_total", n).Msg("AsyncStackdriverWriter: queue full, dropped a log entry")
+}
+
+// Dropped returns the number of entries discarded so far under DropOldest or
+// DropNewest, or because they were written after Close.
+func (a *AsyncStackdriverWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&a.dropped)
+}
+
+// Flush blocks until every entry queued before this call has reached
+// Stackdriver's client-side buffer, then flushes the underlying
+// logging.Logger so it's actually sent.
+func (a *AsyncStackdriverWriter) Flush() error {
+	ack := make(chan struct{})
+	if a.enqueue(asyncEntry{ack: ack}) {
+		<-ack
+	}
+	return a.w.Flush()
+}
+
+// Close stops accepting new entries, waits for the queue to drain, and
+// flushes the underlying *logging.Logger so queued entries are actually
+// delivered to Stackdriver - returning early with ctx's error if it's done
+// first. It does not close the logging.Logger's client; callers still own
+// that. Intended for use during shutdown (e.g. on SIGTERM in Cloud Run or
+// GKE), bounded by the deployment's grace period.
+func (a *AsyncStackdriverWriter) Close(ctx context.Context) error {
+	a.mu.Lock()
+	alreadyClosed := a.closed
+	if !alreadyClosed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mu.Unlock()
+
+	if alreadyClosed {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		a.drain.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return a.w.Flush()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
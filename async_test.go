@@ -0,0 +1,81 @@
+package logutil
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// newTestAsyncWriter builds an AsyncStackdriverWriter without starting its
+// background goroutine, so tests can exercise the enqueue/drop/closed logic
+// without a live *logging.Logger (which needs a real Stackdriver client).
+func newTestAsyncWriter(bufferSize int, policy OverflowPolicy) *AsyncStackdriverWriter {
+	return &AsyncStackdriverWriter{
+		w:      &StackdriverLoggingWriter{},
+		policy: policy,
+		queue:  make(chan asyncEntry, bufferSize),
+	}
+}
+
+func TestAsyncStackdriverWriterDropNewest(t *testing.T) {
+	a := newTestAsyncWriter(1, DropNewest)
+
+	a.WriteLevel(zerolog.InfoLevel, []byte("first")) // fills the 1-entry buffer
+	a.WriteLevel(zerolog.InfoLevel, []byte("second")) // should be dropped
+
+	if got := a.Dropped(); got != 1 {
+		t.Fatalf("Dropped() = %d, want 1", got)
+	}
+
+	e := <-a.queue
+	if string(e.p) != "first" {
+		t.Fatalf("queue held %q, want the first (retained) entry", e.p)
+	}
+}
+
+func TestAsyncStackdriverWriterDropOldest(t *testing.T) {
+	a := newTestAsyncWriter(1, DropOldest)
+
+	a.WriteLevel(zerolog.InfoLevel, []byte("first"))
+	a.WriteLevel(zerolog.InfoLevel, []byte("second")) // should evict "first"
+
+	if got := a.Dropped(); got != 1 {
+		t.Fatalf("Dropped() = %d, want 1", got)
+	}
+
+	e := <-a.queue
+	if string(e.p) != "second" {
+		t.Fatalf("queue held %q, want the newest (retained) entry", e.p)
+	}
+}
+
+// TestAsyncStackdriverWriterEnqueueAfterCloseDoesNotPanic reproduces the
+// scenario where other goroutines are still calling WriteLevel after Close
+// has closed the queue channel; without the closed guard this sends on a
+// closed channel and panics.
+func TestAsyncStackdriverWriterEnqueueAfterCloseDoesNotPanic(t *testing.T) {
+	a := newTestAsyncWriter(4, DropNewest)
+
+	var wg sync.WaitGroup
+	ready := make(chan struct{})
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-ready
+			a.WriteLevel(zerolog.InfoLevel, []byte("x"))
+		}()
+	}
+
+	// Mirrors what Close does: flip closed, then close the channel. The
+	// writers are released only afterwards, so every send meets a closed
+	// queue.
+	a.mu.Lock()
+	a.closed = true
+	close(a.queue)
+	a.mu.Unlock()
+
+	close(ready)
+	wg.Wait()
+}
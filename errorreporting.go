@@ -0,0 +1,71 @@
+package logutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ServiceContext identifies the service and version an error came from, as
+// required by Stackdriver Error Reporting's payload shape (see
+// cloud.google.com/go/errorreporting).
+type ServiceContext struct {
+	Service string
+	Version string
+}
+
+// errorReportingType is the @type marker Error Reporting's log-based intake
+// looks for on a LogEntry's payload.
+const errorReportingType = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+
+// addErrorReportingFields adds the serviceContext and @type fields Error
+// Reporting requires, and, if a "stack" field from pkgerrors.MarshalStack is
+// present, folds it into message as a textual stack trace so Error
+// Reporting can parse and group the exception.
+func addErrorReportingFields(fields map[string]interface{}, sc ServiceContext) {
+	fields["@type"] = errorReportingType
+	fields["serviceContext"] = map[string]string{
+		"service": sc.Service,
+		"version": sc.Version,
+	}
+
+	stack, ok := fields["stack"]
+	if !ok {
+		return
+	}
+	delete(fields, "stack")
+
+	trace := formatPkgErrorsStack(stack)
+	if trace == "" {
+		return
+	}
+
+	msg, _ := fields["message"].(string)
+	fields["message"] = msg + "\n" + trace
+}
+
+// formatPkgErrorsStack turns the []{func,source,line} frames zerolog's
+// pkgerrors.MarshalStack produces into the textual stack trace Error
+// Reporting's message parser expects.
+func formatPkgErrorsStack(v interface{}) string {
+	frames, ok := v.([]interface{})
+	if !ok {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, raw := range frames {
+		frame, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		// pkgerrors.MarshalStack (zerolog's stack marshaler) writes the
+		// source file under "source", not "file".
+		fn, _ := frame["func"].(string)
+		file, _ := frame["source"].(string)
+		line, _ := frame["line"].(string)
+		fmt.Fprintf(&b, "\tat %s (%s:%s)\n", fn, file, line)
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,55 @@
+package logutil
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// The shape zerolog's github.com/pkg/errors stack marshaler
+// (zerolog/pkgerrors.MarshalStack) actually produces: "source", not "file".
+const marshaledPkgErrorsStack = `[
+	{"func": "main.doThing", "source": "main.go", "line": "42"},
+	{"func": "main.main", "source": "main.go", "line": "10"}
+]`
+
+func TestFormatPkgErrorsStackUsesSourceField(t *testing.T) {
+	var frames interface{}
+	if err := json.Unmarshal([]byte(marshaledPkgErrorsStack), &frames); err != nil {
+		t.Fatalf("unmarshal test fixture: %v", err)
+	}
+
+	trace := formatPkgErrorsStack(frames)
+
+	if strings.Contains(trace, "(:42)") || strings.Contains(trace, "(:10)") {
+		t.Fatalf("formatPkgErrorsStack dropped the source file, got: %q", trace)
+	}
+	if !strings.Contains(trace, "main.go:42") || !strings.Contains(trace, "main.go:10") {
+		t.Fatalf("formatPkgErrorsStack didn't include file:line, got: %q", trace)
+	}
+}
+
+func TestAddErrorReportingFieldsFoldsStackIntoMessage(t *testing.T) {
+	var stack interface{}
+	if err := json.Unmarshal([]byte(marshaledPkgErrorsStack), &stack); err != nil {
+		t.Fatalf("unmarshal test fixture: %v", err)
+	}
+
+	fields := map[string]interface{}{
+		"message": "boom",
+		"stack":   stack,
+	}
+
+	addErrorReportingFields(fields, ServiceContext{Service: "svc", Version: "1"})
+
+	msg, _ := fields["message"].(string)
+	if !strings.Contains(msg, "main.go:42") {
+		t.Fatalf("message doesn't contain the stack trace: %q", msg)
+	}
+	if _, ok := fields["stack"]; ok {
+		t.Fatal("addErrorReportingFields left the raw stack field in the payload")
+	}
+	if fields["@type"] != errorReportingType {
+		t.Fatalf("@type = %v, want %v", fields["@type"], errorReportingType)
+	}
+}
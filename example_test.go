@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"cloud.google.com/go/logging"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
 	"github.com/jonstaryuk/logutil"
@@ -17,9 +18,10 @@ func ExampleStackdriverLoggingWriter() {
 	}
 	defer client.Close()
 
-	slw := logutil.StackdriverLoggingWriter{
-		Logger: client.Logger("my-log-id"),
-		Tee:    logutil.ConsoleWriterIfTerminal(os.Stderr, true),
-	}
-	log.Logger = log.Output(slw)
+	slw := &logutil.StackdriverLoggingWriter{Logger: client.Logger("my-log-id")}
+	mw := logutil.NewMultiLevelWriter(
+		logutil.Sink{Writer: slw, Level: zerolog.TraceLevel},
+		logutil.Sink{Writer: logutil.ConsoleWriterIfTerminal(os.Stderr, true), Level: zerolog.TraceLevel},
+	)
+	log.Logger = log.Output(mw)
 }
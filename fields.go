@@ -0,0 +1,248 @@
+package logutil
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"github.com/rs/zerolog"
+	logpb "google.golang.org/genproto/googleapis/logging/v2"
+)
+
+// FieldMapper promotes fields out of a decoded zerolog payload onto e. Any
+// field a FieldMapper claims should be deleted from fields so that it isn't
+// duplicated in the resulting Entry.Payload.
+type FieldMapper func(fields map[string]interface{}, e *logging.Entry)
+
+// FieldNames configures the zerolog payload keys DefaultFieldMapper looks
+// for. The zero value is DefaultFieldNames.
+type FieldNames struct {
+	Trace        string
+	SpanID       string
+	TraceSampled string
+	HTTPRequest  string
+	Labels       string
+	Timestamp    string
+	InsertID     string
+	Operation    string
+	// Caller is the zerolog field holding a "file:line" string, as written
+	// by zerolog.Logger.With().Caller(). It defaults to
+	// zerolog.CallerFieldName; set it to match a customized
+	// zerolog.CallerFieldName.
+	Caller string
+	// Function, if set, names an additional field holding the calling
+	// function's name, promoted alongside Caller onto
+	// Entry.SourceLocation.Function.
+	Function string
+}
+
+// DefaultFieldNames matches the field names zerolog writes by default (see
+// zerolog.TimestampFieldName) plus the ad hoc names used for
+// Stackdriver-specific data.
+var DefaultFieldNames = FieldNames{
+	Trace:        "trace",
+	SpanID:       "span_id",
+	TraceSampled: "trace_sampled",
+	HTTPRequest:  "httpRequest",
+	Labels:       "labels",
+	Timestamp:    "time",
+	InsertID:     "insertId",
+	Operation:    "operation",
+	Caller:       zerolog.CallerFieldName,
+}
+
+// DefaultFieldMapper is the FieldMapper used when
+// StackdriverLoggingWriter.FieldMapper is nil. It recognizes DefaultFieldNames.
+var DefaultFieldMapper = NewFieldMapper(DefaultFieldNames)
+
+// NewFieldMapper returns a FieldMapper that promotes the fields named by
+// names onto a logging.Entry, leaving any other fields in the payload.
+func NewFieldMapper(names FieldNames) FieldMapper {
+	if names == (FieldNames{}) {
+		names = DefaultFieldNames
+	}
+	return func(fields map[string]interface{}, e *logging.Entry) {
+		if v, ok := takeString(fields, names.Trace); ok {
+			e.Trace = v
+		}
+		if v, ok := takeString(fields, names.SpanID); ok {
+			e.SpanID = v
+		}
+		if v, ok := takeBool(fields, names.TraceSampled); ok {
+			e.TraceSampled = v
+		}
+		if v, ok := takeString(fields, names.InsertID); ok {
+			e.InsertID = v
+		}
+		if v, ok := fields[names.Timestamp]; ok {
+			if s, ok := v.(string); ok {
+				if t, err := time.Parse(time.RFC3339, s); err == nil {
+					e.Timestamp = t
+					delete(fields, names.Timestamp)
+				}
+			}
+		}
+		if v, ok := fields[names.HTTPRequest]; ok {
+			if hr, ok := decodeHTTPRequest(v); ok {
+				e.HTTPRequest = hr
+				delete(fields, names.HTTPRequest)
+			}
+		}
+		if v, ok := fields[names.Labels]; ok {
+			if labels, ok := decodeLabels(v); ok {
+				e.Labels = labels
+				delete(fields, names.Labels)
+			}
+		}
+		if v, ok := fields[names.Operation]; ok {
+			if op, ok := decodeOperation(v); ok {
+				e.Operation = op
+				delete(fields, names.Operation)
+			}
+		}
+		if v, ok := takeString(fields, names.Caller); ok {
+			fn, _ := takeString(fields, names.Function)
+			e.SourceLocation = decodeSourceLocation(v, fn)
+		}
+	}
+}
+
+// decodeSourceLocation parses zerolog's "file:line" caller format (as
+// written by zerolog.Logger.With().Caller()) into the
+// logpb.LogEntrySourceLocation Stackdriver displays natively.
+func decodeSourceLocation(caller, function string) *logpb.LogEntrySourceLocation {
+	file, lineStr := caller, ""
+	if i := strings.LastIndexByte(caller, ':'); i >= 0 {
+		file, lineStr = caller[:i], caller[i+1:]
+	}
+
+	line, _ := strconv.ParseInt(lineStr, 10, 64)
+
+	return &logpb.LogEntrySourceLocation{
+		File:     file,
+		Line:     line,
+		Function: function,
+	}
+}
+
+func takeString(fields map[string]interface{}, name string) (string, bool) {
+	if name == "" {
+		return "", false
+	}
+	v, ok := fields[name].(string)
+	if ok {
+		delete(fields, name)
+	}
+	return v, ok
+}
+
+func takeBool(fields map[string]interface{}, name string) (bool, bool) {
+	if name == "" {
+		return false, false
+	}
+	v, ok := fields[name].(bool)
+	if ok {
+		delete(fields, name)
+	}
+	return v, ok
+}
+
+// jsonHTTPRequest mirrors the subset of logging.HTTPRequest that can be
+// logged as plain JSON fields (logging.HTTPRequest itself embeds *http.Request).
+type jsonHTTPRequest struct {
+	RequestMethod string `json:"requestMethod"`
+	RequestURL    string `json:"requestUrl"`
+	Status        int    `json:"status"`
+	RequestSize   int64  `json:"requestSize"`
+	ResponseSize  int64  `json:"responseSize"`
+	RemoteIP      string `json:"remoteIp"`
+	UserAgent     string `json:"userAgent"`
+}
+
+func decodeHTTPRequest(v interface{}) (*logging.HTTPRequest, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	jr := jsonHTTPRequest{}
+	if s, ok := m["requestMethod"].(string); ok {
+		jr.RequestMethod = s
+	}
+	if s, ok := m["requestUrl"].(string); ok {
+		jr.RequestURL = s
+	}
+	if n, ok := m["status"].(float64); ok {
+		jr.Status = int(n)
+	}
+	if n, ok := m["requestSize"].(float64); ok {
+		jr.RequestSize = int64(n)
+	}
+	if n, ok := m["responseSize"].(float64); ok {
+		jr.ResponseSize = int64(n)
+	}
+	if s, ok := m["remoteIp"].(string); ok {
+		jr.RemoteIP = s
+	}
+	if s, ok := m["userAgent"].(string); ok {
+		jr.UserAgent = s
+	}
+
+	// cloud.google.com/go/logging dereferences Request.URL unconditionally,
+	// so a malformed method or URL must fail the decode rather than produce
+	// a Request with a nil URL.
+	req, err := http.NewRequest(jr.RequestMethod, jr.RequestURL, nil)
+	if err != nil {
+		return nil, false
+	}
+	if jr.UserAgent != "" {
+		req.Header = http.Header{"User-Agent": []string{jr.UserAgent}}
+	}
+
+	return &logging.HTTPRequest{
+		Request:      req,
+		RequestSize:  jr.RequestSize,
+		Status:       jr.Status,
+		ResponseSize: jr.ResponseSize,
+		RemoteIP:     jr.RemoteIP,
+	}, true
+}
+
+func decodeLabels(v interface{}) (map[string]string, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	labels := make(map[string]string, len(m))
+	for k, raw := range m {
+		if s, ok := raw.(string); ok {
+			labels[k] = s
+		}
+	}
+	return labels, true
+}
+
+func decodeOperation(v interface{}) (*logpb.LogEntryOperation, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	op := &logpb.LogEntryOperation{}
+	if s, ok := m["id"].(string); ok {
+		op.Id = s
+	}
+	if s, ok := m["producer"].(string); ok {
+		op.Producer = s
+	}
+	if b, ok := m["first"].(bool); ok {
+		op.First = b
+	}
+	if b, ok := m["last"].(bool); ok {
+		op.Last = b
+	}
+	return op, true
+}
@@ -0,0 +1,116 @@
+package logutil
+
+import (
+	"testing"
+
+	"cloud.google.com/go/logging"
+)
+
+func TestDecodeHTTPRequestRejectsMalformedInput(t *testing.T) {
+	cases := []struct {
+		name string
+		v    interface{}
+	}{
+		{"not a map", "nope"},
+		{"invalid method", map[string]interface{}{"requestMethod": "GET /", "requestUrl": "/foo"}},
+		{"invalid url", map[string]interface{}{"requestMethod": "GET", "requestUrl": "http://[::1"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			hr, ok := decodeHTTPRequest(c.v)
+			if ok {
+				t.Fatalf("decodeHTTPRequest(%#v) = %#v, true; want ok=false", c.v, hr)
+			}
+			if hr != nil {
+				t.Fatalf("decodeHTTPRequest(%#v) returned non-nil request on failure: %#v", c.v, hr)
+			}
+		})
+	}
+}
+
+func TestDecodeHTTPRequestPopulatesRequest(t *testing.T) {
+	hr, ok := decodeHTTPRequest(map[string]interface{}{
+		"requestMethod": "GET",
+		"requestUrl":    "https://example.com/foo",
+		"status":        float64(200),
+		"remoteIp":      "10.0.0.1",
+	})
+	if !ok {
+		t.Fatal("decodeHTTPRequest returned ok=false for valid input")
+	}
+	if hr.Request == nil || hr.Request.URL == nil {
+		t.Fatal("decodeHTTPRequest returned a Request with a nil URL")
+	}
+	if hr.Status != 200 || hr.RemoteIP != "10.0.0.1" {
+		t.Fatalf("decodeHTTPRequest didn't populate Status/RemoteIP: %#v", hr)
+	}
+}
+
+func TestNewFieldMapperLeavesUnclaimedHTTPRequestInPayload(t *testing.T) {
+	fields := map[string]interface{}{
+		"httpRequest": map[string]interface{}{"requestMethod": "GET /", "requestUrl": "/foo"},
+		"message":     "hi",
+	}
+
+	var e logging.Entry
+	DefaultFieldMapper(fields, &e)
+
+	if e.HTTPRequest != nil {
+		t.Fatalf("DefaultFieldMapper set HTTPRequest from malformed input: %#v", e.HTTPRequest)
+	}
+	if _, ok := fields["httpRequest"]; !ok {
+		t.Fatal("DefaultFieldMapper deleted httpRequest from the payload despite failing to decode it")
+	}
+}
+
+func TestNewFieldMapperLeavesUnclaimedTimestampInPayload(t *testing.T) {
+	fields := map[string]interface{}{
+		"time":    "garbage",
+		"message": "hi",
+	}
+
+	var e logging.Entry
+	DefaultFieldMapper(fields, &e)
+
+	if !e.Timestamp.IsZero() {
+		t.Fatalf("DefaultFieldMapper set Timestamp from malformed input: %v", e.Timestamp)
+	}
+	if _, ok := fields["time"]; !ok {
+		t.Fatal("DefaultFieldMapper deleted time from the payload despite failing to parse it")
+	}
+}
+
+func TestNewFieldMapperLeavesUnclaimedLabelsInPayload(t *testing.T) {
+	fields := map[string]interface{}{
+		"labels":  "not-a-map",
+		"message": "hi",
+	}
+
+	var e logging.Entry
+	DefaultFieldMapper(fields, &e)
+
+	if e.Labels != nil {
+		t.Fatalf("DefaultFieldMapper set Labels from malformed input: %#v", e.Labels)
+	}
+	if _, ok := fields["labels"]; !ok {
+		t.Fatal("DefaultFieldMapper deleted labels from the payload despite failing to decode it")
+	}
+}
+
+func TestNewFieldMapperLeavesUnclaimedOperationInPayload(t *testing.T) {
+	fields := map[string]interface{}{
+		"operation": "not-a-map",
+		"message":   "hi",
+	}
+
+	var e logging.Entry
+	DefaultFieldMapper(fields, &e)
+
+	if e.Operation != nil {
+		t.Fatalf("DefaultFieldMapper set Operation from malformed input: %#v", e.Operation)
+	}
+	if _, ok := fields["operation"]; !ok {
+		t.Fatal("DefaultFieldMapper deleted operation from the payload despite failing to decode it")
+	}
+}
@@ -0,0 +1,131 @@
+package logutil
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"cloud.google.com/go/logging"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// FileOptions configures a FileWriter.
+type FileOptions struct {
+	// MaxSize is the maximum size in megabytes of the log file before it gets
+	// rotated.
+	MaxSize int
+	// MaxBackups is the maximum number of old log files to retain.
+	MaxBackups int
+	// MaxAge is the maximum number of days to retain old log files.
+	MaxAge int
+	// Compress determines whether rotated log files are gzip-compressed.
+	Compress bool
+	// LocalTime determines whether rotated file timestamps use the host's
+	// local time instead of UTC.
+	LocalTime bool
+	// DirPerm is the permission used if the log file's directory doesn't
+	// already exist. It defaults to 0755.
+	DirPerm os.FileMode
+
+	// There is deliberately no FilePerm: lumberjack always creates log
+	// files, including ones produced by rotation, with a hardcoded 0600 and
+	// doesn't expose a way to override that, so we can't honor a per-file
+	// permission here without it silently reverting on the next rotation.
+}
+
+// FileWriter is a zerolog.LevelWriter that writes to a local file, rotated
+// by gopkg.in/natefinch/lumberjack.v2. It doesn't filter by level; use
+// MultiLevelWriter for per-sink level gating.
+type FileWriter struct {
+	*lumberjack.Logger
+}
+
+// NewFileWriter opens (creating its parent directory if necessary) a
+// rotating log file at path.
+func NewFileWriter(path string, opts FileOptions) (*FileWriter, error) {
+	perm := opts.DirPerm
+	if perm == 0 {
+		perm = 0755
+	}
+	if err := os.MkdirAll(filepath.Dir(path), perm); err != nil {
+		return nil, errors.Wrap(err, "create log directory")
+	}
+
+	return &FileWriter{&lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    opts.MaxSize,
+		MaxBackups: opts.MaxBackups,
+		MaxAge:     opts.MaxAge,
+		Compress:   opts.Compress,
+		LocalTime:  opts.LocalTime,
+	}}, nil
+}
+
+// WriteLevel implements zerolog.LevelWriter.
+func (w *FileWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	return w.Write(p)
+}
+
+// UseFileLogging causes the global zerolog/log.Logger to write to a rotating
+// local file at path. The returned io.Closer should be closed before the
+// program exits.
+func UseFileLogging(path string, opts FileOptions) (io.Closer, error) {
+	fw, err := NewFileWriter(path, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Logger = zerolog.New(fw)
+
+	return fw, nil
+}
+
+// UseMultiLogging causes the global zerolog/log.Logger to write to
+// Stackdriver, a rotating local file, and (if stderr is a terminal) the
+// console, all at once. This covers binaries that run both in GCP and
+// on-prem, where Stackdriver is unavailable.
+//
+// The returned io.Closer flushes and closes both the Stackdriver client and
+// the file writer; it should be closed before the program exits.
+func UseMultiLogging(project, logID string, labels map[string]string, filePath string, fileOpts FileOptions) (io.Closer, error) {
+	client, err := logging.NewClient(context.Background(), "projects/"+project)
+	if err != nil {
+		return nil, errors.Wrap(err, "create client")
+	}
+	if err := client.Ping(context.Background()); err != nil {
+		return nil, errors.Wrap(err, "ping")
+	}
+
+	fw, err := NewFileWriter(filePath, fileOpts)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	slw := &StackdriverLoggingWriter{Logger: client.Logger(logID, logging.CommonLabels(labels))}
+	log.Logger = zerolog.New(NewMultiLevelWriter(
+		Sink{Writer: slw, Level: zerolog.TraceLevel},
+		Sink{Writer: fw, Level: zerolog.TraceLevel},
+		Sink{Writer: ConsoleWriterIfTerminal(os.Stderr, true), Level: zerolog.TraceLevel},
+	))
+
+	return multiCloser{client, fw}, nil
+}
+
+// multiCloser closes the Stackdriver client and the file writer together.
+type multiCloser struct {
+	client *logging.Client
+	file   io.Closer
+}
+
+func (c multiCloser) Close() error {
+	fileErr := c.file.Close()
+	if err := c.client.Close(); err != nil {
+		return err
+	}
+	return fileErr
+}
@@ -0,0 +1,69 @@
+package logutil
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+// TraceHook returns a zerolog.Hook that stamps each event with the trace and
+// span IDs found in ctx, so that events logged via log.Ctx(ctx) correlate
+// with Stackdriver's trace UI once DefaultFieldMapper lifts them onto the
+// logging.Entry.
+//
+// It prefers an OpenTelemetry span in ctx and falls back to the
+// "x-cloud-trace-context" gRPC metadata header that Cloud Run, GKE Ingress,
+// and the Stackdriver client libraries all set on inbound requests. project
+// is the GCP project ID, used to build the "projects/P/traces/T" form
+// Entry.Trace expects.
+func TraceHook(ctx context.Context, project string) zerolog.Hook {
+	return zerolog.HookFunc(func(e *zerolog.Event, level zerolog.Level, msg string) {
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			e.Str(DefaultFieldNames.Trace, "projects/"+project+"/traces/"+sc.TraceID().String())
+			e.Str(DefaultFieldNames.SpanID, sc.SpanID().String())
+			e.Bool(DefaultFieldNames.TraceSampled, sc.IsSampled())
+			return
+		}
+
+		md, ok := metadata.FromIncomingContext(ctx)
+		if !ok {
+			return
+		}
+		hdr := md.Get("x-cloud-trace-context")
+		if len(hdr) == 0 {
+			return
+		}
+
+		traceID, spanID, sampled, ok := parseCloudTraceContext(hdr[0])
+		if !ok {
+			return
+		}
+		e.Str(DefaultFieldNames.Trace, "projects/"+project+"/traces/"+traceID)
+		if spanID != "" {
+			e.Str(DefaultFieldNames.SpanID, spanID)
+		}
+		e.Bool(DefaultFieldNames.TraceSampled, sampled)
+	})
+}
+
+// parseCloudTraceContext parses the "TRACE_ID/SPAN_ID;o=TRACE_TRUE" format of
+// the X-Cloud-Trace-Context header.
+func parseCloudTraceContext(h string) (traceID, spanID string, sampled, ok bool) {
+	traceID, rest, found := strings.Cut(h, "/")
+	if traceID == "" {
+		return "", "", false, false
+	}
+	if !found {
+		return traceID, "", false, true
+	}
+
+	if i := strings.IndexByte(rest, ';'); i >= 0 {
+		spanID, sampled = rest[:i], strings.Contains(rest[i:], "o=1")
+	} else {
+		spanID = rest
+	}
+	return traceID, spanID, sampled, true
+}
@@ -0,0 +1,111 @@
+package logutil
+
+import (
+	"io"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// Sink is one destination in a MultiLevelWriter: Writer, gated by a minimum
+// Level, with an optional OnError hook invoked instead of aborting the write
+// when Writer returns an error.
+type Sink struct {
+	Writer  io.Writer
+	Level   zerolog.Level
+	OnError func(error)
+}
+
+// MultiLevelWriter fans writes out to any number of Sinks, each
+// independently gated by its own minimum level (e.g. console at Info,
+// Stackdriver at Warn, a rotated file at Debug). A write or flush error from
+// one sink is reported to that sink's OnError, if set, instead of aborting
+// delivery to the others.
+//
+// It's safe for concurrent Write, WriteLevel, Flush, Add, and Remove.
+type MultiLevelWriter struct {
+	mu    sync.RWMutex
+	sinks []Sink
+}
+
+// NewMultiLevelWriter returns a MultiLevelWriter fanning out to sinks.
+func NewMultiLevelWriter(sinks ...Sink) *MultiLevelWriter {
+	return &MultiLevelWriter{sinks: append([]Sink(nil), sinks...)}
+}
+
+// Add appends a sink.
+func (w *MultiLevelWriter) Add(s Sink) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.sinks = append(w.sinks, s)
+}
+
+// Remove removes every sink whose Writer is writer.
+func (w *MultiLevelWriter) Remove(writer io.Writer) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	kept := w.sinks[:0]
+	for _, s := range w.sinks {
+		if s.Writer != writer {
+			kept = append(kept, s)
+		}
+	}
+	w.sinks = kept
+}
+
+// Write implements io.Writer. It writes p to every sink, ignoring Level.
+func (w *MultiLevelWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel implements zerolog.LevelWriter. It writes p to every sink whose
+// Level is at or below level.
+func (w *MultiLevelWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	for _, s := range w.sinks {
+		if level != zerolog.NoLevel && level < s.Level {
+			continue
+		}
+
+		var err error
+		if lw, ok := s.Writer.(zerolog.LevelWriter); ok {
+			_, err = lw.WriteLevel(level, p)
+		} else {
+			_, err = s.Writer.Write(p)
+		}
+
+		if err != nil && s.OnError != nil {
+			s.OnError(err)
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush calls Flush on every sink whose Writer implements it ("Flush()
+// error"), collecting (but not aborting on) errors. If any sink lacking an
+// OnError handler fails to flush, Flush returns that sink's error.
+func (w *MultiLevelWriter) Flush() error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var firstErr error
+	for _, s := range w.sinks {
+		f, ok := s.Writer.(interface{ Flush() error })
+		if !ok {
+			continue
+		}
+
+		if err := f.Flush(); err != nil {
+			if s.OnError != nil {
+				s.OnError(err)
+			} else if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
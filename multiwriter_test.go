@@ -0,0 +1,104 @@
+package logutil
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+type countingWriter struct {
+	mu    sync.Mutex
+	n     int
+	erroc error
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.n++
+	return len(p), w.erroc
+}
+
+func (w *countingWriter) count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.n
+}
+
+func TestMultiLevelWriterLevelGating(t *testing.T) {
+	quiet := &countingWriter{}
+	verbose := &countingWriter{}
+
+	mw := NewMultiLevelWriter(
+		Sink{Writer: quiet, Level: zerolog.WarnLevel},
+		Sink{Writer: verbose, Level: zerolog.DebugLevel},
+	)
+
+	mw.WriteLevel(zerolog.InfoLevel, []byte("hi"))
+
+	if quiet.count() != 0 {
+		t.Fatalf("quiet sink (min Warn) got an Info-level write: %d writes", quiet.count())
+	}
+	if verbose.count() != 1 {
+		t.Fatalf("verbose sink (min Debug) should have gotten the Info-level write: %d writes", verbose.count())
+	}
+}
+
+func TestMultiLevelWriterErrorDoesNotAbortOtherSinks(t *testing.T) {
+	failing := &countingWriter{erroc: errors.New("boom")}
+	var onErrCalls int
+	ok := &countingWriter{}
+
+	mw := NewMultiLevelWriter(
+		Sink{Writer: failing, Level: zerolog.TraceLevel, OnError: func(error) { onErrCalls++ }},
+		Sink{Writer: ok, Level: zerolog.TraceLevel},
+	)
+
+	if _, err := mw.WriteLevel(zerolog.InfoLevel, []byte("hi")); err != nil {
+		t.Fatalf("WriteLevel returned an error: %v", err)
+	}
+	if onErrCalls != 1 {
+		t.Fatalf("OnError called %d times, want 1", onErrCalls)
+	}
+	if ok.count() != 1 {
+		t.Fatalf("sink after the failing one didn't get written to: %d writes", ok.count())
+	}
+}
+
+func TestMultiLevelWriterConcurrentAddRemoveWrite(t *testing.T) {
+	mw := NewMultiLevelWriter()
+
+	var wg sync.WaitGroup
+	writers := make([]*countingWriter, 20)
+	for i := range writers {
+		writers[i] = &countingWriter{}
+	}
+
+	for _, w := range writers {
+		w := w
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			mw.Add(Sink{Writer: w, Level: zerolog.TraceLevel})
+		}()
+		go func() {
+			defer wg.Done()
+			mw.WriteLevel(zerolog.InfoLevel, []byte("hi"))
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mw.WriteLevel(zerolog.InfoLevel, []byte("hi"))
+		}()
+	}
+
+	wg.Wait()
+
+	for _, w := range writers {
+		mw.Remove(w)
+	}
+}
@@ -2,6 +2,7 @@ package logutil
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"os"
@@ -17,20 +18,26 @@ import (
 // them to Google Stackdriver Logging. It implements zerolog.LevelWriter and
 // maps Zerolog levels to Stackdriver levels.
 //
-// If Tee is not nil, it receives a copy of each write.
+// FieldMapper, if not nil, is used to promote fields out of each payload
+// (e.g. trace, httpRequest) onto the logging.Entry proper, where the Logs
+// Explorer understands them specially. It defaults to DefaultFieldMapper.
+//
+// To also write elsewhere (console, a file, ...), wrap a
+// StackdriverLoggingWriter in a MultiLevelWriter rather than teeing here.
+//
+// If ReportErrors is true, error/fatal/panic-level entries are additionally
+// shaped for Stackdriver Error Reporting; see ServiceContext.
 type StackdriverLoggingWriter struct {
-	Logger *logging.Logger
-	Tee    io.Writer
+	Logger      *logging.Logger
+	FieldMapper FieldMapper
+
+	ServiceContext ServiceContext
+	ReportErrors   bool
 }
 
 // Write always returns len(p), nil.
 func (w *StackdriverLoggingWriter) Write(p []byte) (int, error) {
-	w.Logger.Log(logging.Entry{Payload: rawJSON(p)})
-
-	if w.Tee != nil {
-		w.Tee.Write(p)
-	}
-
+	w.Logger.Log(w.entry(p, logging.Default))
 	return len(p), nil
 }
 
@@ -54,17 +61,38 @@ func (w *StackdriverLoggingWriter) WriteLevel(level zerolog.Level, p []byte) (in
 		severity = logging.Critical
 	}
 
-	w.Logger.Log(logging.Entry{Payload: rawJSON(p), Severity: severity})
+	w.Logger.Log(w.entry(p, severity))
+	return len(p), nil
+}
 
-	if w.Tee != nil {
-		if lw, ok := w.Tee.(zerolog.LevelWriter); ok {
-			lw.WriteLevel(level, p)
-		} else {
-			w.Tee.Write(p)
-		}
+// entry decodes p, promotes any fields the FieldMapper claims onto a
+// logging.Entry, and leaves the rest as the entry's JSON payload.
+func (w *StackdriverLoggingWriter) entry(p []byte, severity logging.Severity) logging.Entry {
+	mapper := w.FieldMapper
+	if mapper == nil {
+		mapper = DefaultFieldMapper
 	}
 
-	return len(p), nil
+	var fields map[string]interface{}
+	if err := json.Unmarshal(p, &fields); err != nil {
+		// Not a JSON object; ship it unmodified.
+		return logging.Entry{Payload: rawJSON(p), Severity: severity}
+	}
+
+	e := logging.Entry{Severity: severity}
+	mapper(fields, &e)
+
+	if w.ReportErrors && severity >= logging.Error {
+		addErrorReportingFields(fields, w.ServiceContext)
+	}
+
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return logging.Entry{Payload: rawJSON(p), Severity: severity}
+	}
+	e.Payload = rawJSON(payload)
+
+	return e
 }
 
 func (w *StackdriverLoggingWriter) Flush() error {